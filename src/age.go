@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ageBucketStat accumulates the size and count of files falling into one age bucket, the
+// histogram equivalent of the plain size/count counters already kept on fileType and userInfo
+type ageBucketStat struct {
+	size  int64
+	count int64
+}
+
+// defaultAgeBuckets is the boundary list used when -age-buckets is left at its default: under a
+// week, under a month, under a quarter, under a year, under two years, and everything older
+const defaultAgeBuckets = "7,30,90,365,730"
+
+// func parseAgeBuckets parses a comma-separated list of strictly increasing, positive day
+// boundaries (as accepted by -age-buckets) into the []int64 form addFile classifies against
+func parseAgeBuckets(s string) ([]int64, error) {
+	fields := strings.Split(s, ",")
+	boundaries := make([]int64, 0, len(fields))
+	var prev int64
+	for _, f := range fields {
+		days, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age bucket boundary %q: %w", f, err)
+		}
+		if days <= 0 {
+			return nil, fmt.Errorf("age bucket boundary %d must be positive", days)
+		}
+		if days <= prev {
+			return nil, fmt.Errorf("age bucket boundaries must be strictly increasing, got %d after %d", days, prev)
+		}
+		boundaries = append(boundaries, days)
+		prev = days
+	}
+	return boundaries, nil
+}
+
+// func ageBucketLabels renders the human-readable label for each bucket implied by boundaries, in
+// the same order ageBucketIndex classifies into: "<7d", "7-30d", ..., ">730d"
+func ageBucketLabels(boundaries []int64) []string {
+	labels := make([]string, len(boundaries)+1)
+	var prev int64
+	for i, b := range boundaries {
+		if i == 0 {
+			labels[i] = fmt.Sprintf("<%dd", b)
+		} else {
+			labels[i] = fmt.Sprintf("%d-%dd", prev, b)
+		}
+		prev = b
+	}
+	labels[len(boundaries)] = fmt.Sprintf(">%dd", prev)
+	return labels
+}
+
+// func ageBucketIndex returns which bucket a file last modified at mtime falls into, given the
+// reference time now and the (ascending, in days) boundaries parsed by parseAgeBuckets
+func ageBucketIndex(mtime int64, now int64, boundaries []int64) int {
+	ageDays := (now - mtime) / 86400
+	for i, b := range boundaries {
+		if ageDays < b {
+			return i
+		}
+	}
+	return len(boundaries)
+}
+
+// func newAgeHistogram allocates a zeroed bucket for every boundary r was constructed with, plus
+// one for everything older than the last boundary
+func (r *scanResult) newAgeHistogram() []ageBucketStat {
+	return make([]ageBucketStat, len(r.ageBoundaries)+1)
+}
+
+// func addAgeBucket records size against mtime's bucket in buckets, growing no further than the
+// histogram already allocated by newAgeHistogram
+func (r *scanResult) addAgeBucket(buckets []ageBucketStat, mtime int64, size int64) {
+	b := &buckets[ageBucketIndex(mtime, r.now, r.ageBoundaries)]
+	b.size += size
+	b.count++
+}
+
+// func subAgeBucket reverses a prior addAgeBucket, used when a diff record removes a previously
+// indexed file from the aggregates
+func (r *scanResult) subAgeBucket(buckets []ageBucketStat, mtime int64, size int64) {
+	b := &buckets[ageBucketIndex(mtime, r.now, r.ageBoundaries)]
+	b.size -= size
+	b.count--
+}
+
+// func mergeAgeBuckets adds src's per-bucket totals into dst in place, the histogram equivalent of
+// summing the size/count counters during mergeScanResult
+func mergeAgeBuckets(dst []ageBucketStat, src []ageBucketStat) {
+	for i := range src {
+		dst[i].size += src[i].size
+		dst[i].count += src[i].count
+	}
+}
+
+// func formatAgeHistogram renders labels/buckets as the single line "<7d: 1.2 GiB (3), ..." that
+// TextReporter prints after an "age: " prefix under each user and extension
+func formatAgeHistogram(labels []string, buckets []ageBucketStat) string {
+	parts := make([]string, len(buckets))
+	for i, b := range buckets {
+		parts[i] = fmt.Sprintf("%s: %s (%d)", labels[i], humanReadableSize(b.size), b.count)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// func subtractFile removes a previously recorded file's contribution from a scanResult's
+// aggregates, deleting any extension/user entry that drops to zero so stale rows don't linger in
+// the report.
+func (r *scanResult) subtractFile(path string, extension string, owner string, size int64, mtime int64) {
+	if ft, ok := r.fileTypes[extension]; ok {
+		ft.size -= size
+		ft.count--
+		r.subAgeBucket(ft.ageBuckets, mtime, size)
+		if ftUser, ok := ft.users[owner]; ok {
+			ftUser.size -= size
+			ftUser.count--
+			r.subAgeBucket(ftUser.ageBuckets, mtime, size)
+			if ftUser.count <= 0 {
+				delete(ft.users, owner)
+			}
+		}
+		if ft.count <= 0 {
+			delete(r.fileTypes, extension)
+		}
+	}
+
+	if u, ok := r.users[owner]; ok {
+		u.size -= size
+		u.count--
+		r.subAgeBucket(u.ageBuckets, mtime, size)
+		if uft, ok := u.filetypes[extension]; ok {
+			uft.size -= size
+			uft.count--
+			r.subAgeBucket(uft.ageBuckets, mtime, size)
+			if uft.count <= 0 {
+				delete(u.filetypes, extension)
+			}
+		}
+		if u.count <= 0 {
+			delete(r.users, owner)
+		}
+	}
+
+	parentDir := filepath.Dir(path)
+	if dir, ok := r.dirs[parentDir]; ok {
+		dir.ownSize -= size
+		dir.ownCount--
+		if dir.ownCount <= 0 {
+			delete(r.dirs, parentDir)
+		}
+	}
+
+	removeFileCandidate(&r.topFiles, path)
+
+	delete(r.files, path)
+}
+
+// func removeIndexedFile subtracts an already-indexed file at path from result's aggregates and
+// drops it from idx, used when a diff record reports the path was removed or is about to be
+// replaced by a new version.
+func removeIndexedFile(path string, idx *index, result *scanResult) {
+	rec, ok := idx.Records[path]
+	if !ok {
+		return
+	}
+	result.subtractFile(path, rec.Ext, ownerOf(rec.Uid), rec.Size, rec.Mtime)
+	delete(idx.Records, path)
+}
+
+// func addStatedFile stats path and, if it still exists, adds its current contents to result's
+// aggregates and records it in idx, used when a diff record reports the path was added or changed.
+func addStatedFile(path string, idx *index, result *scanResult, classifyAll bool, verboseEnabled bool, logger *log.Logger) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if verboseEnabled {
+			logger.Printf("Error stating %s from diff record, skipping: %s\n", path, err)
+		}
+		return
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+
+	extension := classifyFile(path, filepath.Ext(path), classifyAll, verboseEnabled, logger)
+
+	size := info.Size()
+	mtime := info.ModTime().Unix()
+	result.addFile(path, extension, ownerOf(stat.Uid), stat.Uid, size, mtime)
+	idx.Records[path] = fileRecord{Size: size, Uid: stat.Uid, Mtime: mtime, Ext: extension}
+}
+
+// func applyDiffRecord applies a single zfs-diff/btrfs-find-new style change record to idx and
+// result: "M\tF\t/path", "+\tF\t/path", "-\tF\t/path" or "R\tF\t/old\t/new". Only plain file (F)
+// records carry size/owner information we can aggregate, so directory records are skipped.
+func applyDiffRecord(line string, idx *index, result *scanResult, classifyAll bool, verboseEnabled bool, logger *log.Logger) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 || fields[1] != "F" {
+		return
+	}
+
+	switch fields[0] {
+	case "-":
+		removeIndexedFile(fields[2], idx, result)
+	case "+":
+		addStatedFile(fields[2], idx, result, classifyAll, verboseEnabled, logger)
+	case "M":
+		removeIndexedFile(fields[2], idx, result)
+		addStatedFile(fields[2], idx, result, classifyAll, verboseEnabled, logger)
+	case "R":
+		if len(fields) < 4 {
+			return
+		}
+		removeIndexedFile(fields[2], idx, result)
+		addStatedFile(fields[3], idx, result, classifyAll, verboseEnabled, logger)
+	}
+}
+
+// func applyDiff reads change records from diffPath (e.g. the output of `zfs diff snap1 snap2` or
+// `btrfs subvolume find-new`) and incrementally updates idx and result in place, so a recurring
+// scan of a large but slowly-changing tree only pays for what actually changed instead of a full
+// re-walk.
+func applyDiff(diffPath string, idx *index, result *scanResult, classifyAll bool, verboseEnabled bool, logger *log.Logger) error {
+	f, err := os.Open(diffPath)
+	if err != nil {
+		return fmt.Errorf("opening diff file %s: %w", diffPath, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		applyDiffRecord(line, idx, result, classifyAll, verboseEnabled, logger)
+	}
+	return scanner.Err()
+}
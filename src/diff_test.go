@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// indexFileForTest stats path and records it in both idx and result the way a real scan would,
+// giving diff tests a "prior scan" to apply change records against without walking a filesystem.
+func indexFileForTest(t *testing.T, idx *index, result *scanResult, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat %s: %v", path, err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	size := info.Size()
+	mtime := info.ModTime().Unix()
+	ext := filepath.Ext(path)
+	result.addFile(path, ext, ownerOf(stat.Uid), stat.Uid, size, mtime)
+	idx.Records[path] = fileRecord{Size: size, Uid: stat.Uid, Mtime: mtime, Ext: ext}
+}
+
+// TestApplyDiffAddRemoveModifyRename walks applyDiffRecord through one of each record kind
+// ("+", "-", "M", "R") against a small prior index, and checks both the index and the aggregates
+// end up reflecting only the files that still exist on disk.
+func TestApplyDiffAddRemoveModifyRename(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile %s: %v", p, err)
+		}
+		return p
+	}
+
+	oldPath := write("old.txt", "hello")
+	keepPath := write("keep.txt", "xx")
+	renameSrc := write("rename_src.txt", "abc")
+
+	idx := newIndex(dir)
+	result := newScanResult(5, nil, 0)
+	indexFileForTest(t, idx, result, oldPath)
+	indexFileForTest(t, idx, result, keepPath)
+	indexFileForTest(t, idx, result, renameSrc)
+
+	// now mutate the filesystem to match the change records applied below
+	if err := os.Remove(oldPath); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	newPath := write("new.txt", "brand new")
+	if err := os.WriteFile(keepPath, []byte("xx-modified"), 0644); err != nil {
+		t.Fatalf("rewrite keep.txt: %v", err)
+	}
+	renameDst := filepath.Join(dir, "rename_dst.txt")
+	if err := os.Rename(renameSrc, renameDst); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	applyDiffRecord("-\tF\t"+oldPath, idx, result, false, false, logger)
+	applyDiffRecord("+\tF\t"+newPath, idx, result, false, false, logger)
+	applyDiffRecord("M\tF\t"+keepPath, idx, result, false, false, logger)
+	applyDiffRecord("R\tF\t"+renameSrc+"\t"+renameDst, idx, result, false, false, logger)
+
+	for _, removed := range []string{oldPath, renameSrc} {
+		if _, ok := idx.Records[removed]; ok {
+			t.Errorf("idx.Records still has removed path %s", removed)
+		}
+		if _, ok := result.files[removed]; ok {
+			t.Errorf("result.files still has removed path %s", removed)
+		}
+	}
+	for _, present := range []string{newPath, keepPath, renameDst} {
+		if _, ok := idx.Records[present]; !ok {
+			t.Errorf("idx.Records missing expected path %s", present)
+		}
+		if _, ok := result.files[present]; !ok {
+			t.Errorf("result.files missing expected path %s", present)
+		}
+	}
+
+	if rec := idx.Records[keepPath]; rec.Size != int64(len("xx-modified")) {
+		t.Errorf("keep.txt size after modify = %d, want %d", rec.Size, len("xx-modified"))
+	}
+
+	wantTotal := int64(len("brand new") + len("xx-modified") + len("abc"))
+	ft, ok := result.fileTypes[".txt"]
+	if !ok {
+		t.Fatalf(".txt fileType missing after diff")
+	}
+	if ft.size != wantTotal || ft.count != 3 {
+		t.Errorf(".txt fileType = size %d count %d, want size %d count 3", ft.size, ft.count, wantTotal)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// indexMagic/indexVersion identify a dirscan index file and its on-disk layout, so loadIndex can
+// reject files written by something else (or by an incompatible future version) up front.
+const (
+	indexMagic   uint32 = 0x44534958 // "DSIX"
+	indexVersion uint16 = 1
+)
+
+// fileRecord is the per-file information retained in an index so a later incremental rescan can
+// diff against it without re-walking the filesystem.
+type fileRecord struct {
+	Size  int64
+	Uid   uint32
+	Mtime int64
+	Ext   string
+}
+
+// index is the full set of per-file records produced by a scan, persisted to disk via saveIndex
+// and reloaded via loadIndex so that incremental updates and repeated reporting don't require a
+// fresh walk of the filesystem.
+//
+// On disk an index is a header (magic, version, scan root, generation timestamp) followed by a
+// stream of records, one per file, written in path order:
+//
+//	[uint16 pathLen][path bytes][uint64 size][uint32 uid][uint32 mtime][uint16 extLen][ext bytes]
+//
+// Writing records in path order keeps the on-disk format itself mergeable/diffable a chunk at a
+// time. saveIndex and loadIndex don't yet take advantage of that: saveIndex still sorts every
+// path in one in-memory slice before writing, and loadIndex (like Records below) materializes one
+// map entry per file unconditionally. Records needs to stay random-access so applyDiff can look a
+// path up by name, which rules out a simple streaming reader - bounding memory on a
+// hundred-million-file tree would need Records itself to spill to disk, which is a bigger change
+// than this format alone; tracked as follow-up work rather than attempted here.
+type index struct {
+	Root        string
+	GeneratedAt time.Time
+	Records     map[string]fileRecord
+}
+
+// func newIndex allocates an empty index rooted at root
+func newIndex(root string) *index {
+	return &index{Root: root, GeneratedAt: time.Now(), Records: make(map[string]fileRecord)}
+}
+
+// func newIndexFromResult builds an index out of the per-file records collected during a scan
+func newIndexFromResult(root string, result *scanResult) *index {
+	idx := newIndex(root)
+	for p, rec := range result.files {
+		idx.Records[p] = rec
+	}
+	return idx
+}
+
+// func writeString writes a length-prefixed string, the shape shared by both the path and
+// extension fields of an index record
+func writeString(w io.Writer, s string) error {
+	if len(s) > math.MaxUint16 {
+		return fmt.Errorf("string %q is too long to index (%d bytes)", s, len(s))
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// func readString reads back a string written by writeString
+func readString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// func writeRecord writes one path's fileRecord in the on-disk layout documented on index
+func writeRecord(w io.Writer, path string, rec fileRecord) error {
+	if err := writeString(w, path); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint64(rec.Size)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, rec.Uid); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(rec.Mtime)); err != nil {
+		return err
+	}
+	return writeString(w, rec.Ext)
+}
+
+// func readRecord reads back one path/fileRecord pair written by writeRecord
+func readRecord(r io.Reader) (string, fileRecord, error) {
+	path, err := readString(r)
+	if err != nil {
+		return "", fileRecord{}, err
+	}
+
+	var size uint64
+	var uid uint32
+	var mtime uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return "", fileRecord{}, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &uid); err != nil {
+		return "", fileRecord{}, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &mtime); err != nil {
+		return "", fileRecord{}, err
+	}
+	ext, err := readString(r)
+	if err != nil {
+		return "", fileRecord{}, err
+	}
+
+	return path, fileRecord{Size: int64(size), Uid: uid, Mtime: int64(mtime), Ext: ext}, nil
+}
+
+// func saveIndex persists idx to path in the streaming format documented on index, so it can be
+// reloaded by a later run via loadIndex
+func saveIndex(path string, idx *index) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := binary.Write(w, binary.BigEndian, indexMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, indexVersion); err != nil {
+		return err
+	}
+	if err := writeString(w, idx.Root); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, idx.GeneratedAt.Unix()); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(idx.Records))
+	for p := range idx.Records {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if err := writeRecord(w, p, idx.Records[p]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// func loadIndex reads back an index previously written by saveIndex
+func loadIndex(path string) (*index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var magic uint32
+	if err := binary.Read(r, binary.BigEndian, &magic); err != nil {
+		return nil, err
+	}
+	if magic != indexMagic {
+		return nil, fmt.Errorf("%s is not a dirscan index", path)
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != indexVersion {
+		return nil, fmt.Errorf("%s was written by an unsupported dirscan index version %d", path, version)
+	}
+
+	root, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var generatedAtUnix int64
+	if err := binary.Read(r, binary.BigEndian, &generatedAtUnix); err != nil {
+		return nil, err
+	}
+
+	idx := newIndex(root)
+	idx.GeneratedAt = time.Unix(generatedAtUnix, 0)
+
+	for {
+		p, rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx.Records[p] = rec
+	}
+	return idx, nil
+}
+
+// func ownerOf resolves the username for a uid recorded in an index record, falling back to the
+// numeric uid if it no longer resolves to a known account
+func ownerOf(uid uint32) string {
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	return name
+}
+
+// func aggregateFromIndex rebuilds the extension/user rollups from a loaded index's per-file
+// records, so reporting can run against a persisted index without re-walking the filesystem
+func aggregateFromIndex(idx *index, topN int, ageBoundaries []int64, now int64) *scanResult {
+	result := newScanResult(topN, ageBoundaries, now)
+	for path, rec := range idx.Records {
+		result.addFile(path, rec.Ext, ownerOf(rec.Uid), rec.Uid, rec.Size, rec.Mtime)
+	}
+	return result
+}
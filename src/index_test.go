@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteReadRecordRoundTrip exercises the binary per-record encoding used inside the streaming
+// index format: what writeRecord puts on the wire, readRecord must read back unchanged.
+func TestWriteReadRecordRoundTrip(t *testing.T) {
+	want := fileRecord{Size: 123456789, Uid: 1000, Mtime: 1700000000, Ext: ".parquet"}
+
+	var buf bytes.Buffer
+	if err := writeRecord(&buf, "/data/warehouse/events.parquet", want); err != nil {
+		t.Fatalf("writeRecord: %v", err)
+	}
+
+	path, got, err := readRecord(&buf)
+	if err != nil {
+		t.Fatalf("readRecord: %v", err)
+	}
+	if path != "/data/warehouse/events.parquet" {
+		t.Errorf("path = %q, want /data/warehouse/events.parquet", path)
+	}
+	if got != want {
+		t.Errorf("record = %+v, want %+v", got, want)
+	}
+}
+
+// TestSaveLoadIndexRoundTrip exercises the full on-disk format: saveIndex writes a header plus a
+// path-sorted stream of records, and loadIndex must reconstruct the same root and records.
+func TestSaveLoadIndexRoundTrip(t *testing.T) {
+	idx := newIndex("/data/warehouse")
+	idx.Records["/data/warehouse/b.txt"] = fileRecord{Size: 20, Uid: 2, Mtime: 200, Ext: ".txt"}
+	idx.Records["/data/warehouse/a.txt"] = fileRecord{Size: 10, Uid: 1, Mtime: 100, Ext: ".txt"}
+
+	path := filepath.Join(t.TempDir(), "idx.bin")
+	if err := saveIndex(path, idx); err != nil {
+		t.Fatalf("saveIndex: %v", err)
+	}
+
+	loaded, err := loadIndex(path)
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+
+	if loaded.Root != idx.Root {
+		t.Errorf("Root = %q, want %q", loaded.Root, idx.Root)
+	}
+	if len(loaded.Records) != len(idx.Records) {
+		t.Fatalf("len(Records) = %d, want %d", len(loaded.Records), len(idx.Records))
+	}
+	for p, rec := range idx.Records {
+		got, ok := loaded.Records[p]
+		if !ok {
+			t.Errorf("record %q missing after reload", p)
+			continue
+		}
+		if got != rec {
+			t.Errorf("record %q = %+v, want %+v", p, got, rec)
+		}
+	}
+}
+
+// TestLoadIndexRejectsForeignFile ensures loadIndex refuses a file that doesn't start with the
+// dirscan index magic, instead of misreading garbage as a truncated index.
+func TestLoadIndexRejectsForeignFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-an-index.bin")
+	if err := os.WriteFile(path, []byte("not a dirscan index"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := loadIndex(path); err == nil {
+		t.Errorf("loadIndex on a non-index file: want error, got nil")
+	}
+
+	if _, err := loadIndex(filepath.Join(t.TempDir(), "missing.bin")); err == nil {
+		t.Errorf("loadIndex on a missing file: want error, got nil")
+	}
+}
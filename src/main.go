@@ -1,18 +1,22 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"net/http"
 	"os"
 	"os/signal"
 	"os/user"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -28,32 +32,185 @@ var (
 
 // Struct defining and used to hold information in the context of files and file extensions
 type fileType struct {
-	extension string
-	size      int64
-	count     int64
-	users     []fileTypeUserInfo
+	extension  string
+	size       int64
+	count      int64
+	users      map[string]*fileTypeUserInfo
+	ageBuckets []ageBucketStat
 }
 
 // Struct defining and holding the user information about a specific file/file extension
 type fileTypeUserInfo struct {
-	name  string
-	size  int64
-	count int64
+	name       string
+	size       int64
+	count      int64
+	ageBuckets []ageBucketStat
 }
 
 // Struct defining a struct used to hold information in the context of a user
 type userInfo struct {
-	name      string
-	size      int64
-	count     int64
-	filetypes []userFileType
+	name       string
+	size       int64
+	count      int64
+	filetypes  map[string]*userFileType
+	ageBuckets []ageBucketStat
 }
 
 // defining a struct used to hold information in the context of file extension for a user
 type userFileType struct {
-	extension string
-	size      int64
-	count     int64
+	extension  string
+	size       int64
+	count      int64
+	ageBuckets []ageBucketStat
+}
+
+// scanResult holds the aggregates built up while walking the tree, keyed by extension/username so
+// that recording a file is an O(1) map lookup instead of a linear scan. Each worker owns one of
+// these for the files it processes directly; they are merged into a single result once the walk
+// finishes. files retains a per-path record of what was counted, so a persisted index can be
+// rebuilt and later diffed against without re-walking the filesystem.
+type scanResult struct {
+	fileTypes     map[string]*fileType
+	users         map[string]*userInfo
+	files         map[string]fileRecord
+	dirs          map[string]*dirStats
+	topFiles      fileHeap
+	topN          int
+	ageBoundaries []int64
+	now           int64
+}
+
+// func newScanResult allocates an empty scanResult ready to accumulate file records. topN bounds
+// the number of largest files/directories retained for the top-N report (pass 0 to disable it);
+// ageBoundaries is the parsed -age-buckets list and now is the reference time file ages are
+// computed against, shared by every result merged out of the same scan.
+func newScanResult(topN int, ageBoundaries []int64, now int64) *scanResult {
+	return &scanResult{
+		fileTypes:     make(map[string]*fileType),
+		users:         make(map[string]*userInfo),
+		files:         make(map[string]fileRecord),
+		dirs:          make(map[string]*dirStats),
+		topN:          topN,
+		ageBoundaries: ageBoundaries,
+		now:           now,
+	}
+}
+
+// func addFile records a single file's size against its extension and owner in this scanResult,
+// remembers the file's path, uid and mtime so it can be indexed or diffed against later, and
+// offers it to the directory's own-size tally and the bounded top-files heap
+func (r *scanResult) addFile(path string, extension string, owner string, uid uint32, size int64, mtime int64) {
+	ft, ok := r.fileTypes[extension]
+	if !ok {
+		ft = &fileType{extension: extension, users: make(map[string]*fileTypeUserInfo), ageBuckets: r.newAgeHistogram()}
+		r.fileTypes[extension] = ft
+	}
+	ft.size += size
+	ft.count++
+	r.addAgeBucket(ft.ageBuckets, mtime, size)
+
+	ftUser, ok := ft.users[owner]
+	if !ok {
+		ftUser = &fileTypeUserInfo{name: owner, ageBuckets: r.newAgeHistogram()}
+		ft.users[owner] = ftUser
+	}
+	ftUser.size += size
+	ftUser.count++
+	r.addAgeBucket(ftUser.ageBuckets, mtime, size)
+
+	u, ok := r.users[owner]
+	if !ok {
+		u = &userInfo{name: owner, filetypes: make(map[string]*userFileType), ageBuckets: r.newAgeHistogram()}
+		r.users[owner] = u
+	}
+	u.size += size
+	u.count++
+	r.addAgeBucket(u.ageBuckets, mtime, size)
+
+	uft, ok := u.filetypes[extension]
+	if !ok {
+		uft = &userFileType{extension: extension, ageBuckets: r.newAgeHistogram()}
+		u.filetypes[extension] = uft
+	}
+	uft.size += size
+	uft.count++
+	r.addAgeBucket(uft.ageBuckets, mtime, size)
+
+	r.files[path] = fileRecord{Size: size, Uid: uid, Mtime: mtime, Ext: extension}
+
+	parentDir := filepath.Dir(path)
+	dir, ok := r.dirs[parentDir]
+	if !ok {
+		dir = &dirStats{}
+		r.dirs[parentDir] = dir
+	}
+	dir.ownSize += size
+	dir.ownCount++
+
+	offerFile(&r.topFiles, r.topN, fileCandidate{path: path, size: size, owner: owner, mtime: mtime})
+}
+
+// func mergeScanResult folds src into dst, summing counters for extensions/users present in both
+func mergeScanResult(dst *scanResult, src *scanResult) {
+	for extension, ft := range src.fileTypes {
+		dstFt, ok := dst.fileTypes[extension]
+		if !ok {
+			dstFt = &fileType{extension: extension, users: make(map[string]*fileTypeUserInfo), ageBuckets: dst.newAgeHistogram()}
+			dst.fileTypes[extension] = dstFt
+		}
+		dstFt.size += ft.size
+		dstFt.count += ft.count
+		mergeAgeBuckets(dstFt.ageBuckets, ft.ageBuckets)
+		for name, ftUser := range ft.users {
+			dstFtUser, ok := dstFt.users[name]
+			if !ok {
+				dstFtUser = &fileTypeUserInfo{name: name, ageBuckets: dst.newAgeHistogram()}
+				dstFt.users[name] = dstFtUser
+			}
+			dstFtUser.size += ftUser.size
+			dstFtUser.count += ftUser.count
+			mergeAgeBuckets(dstFtUser.ageBuckets, ftUser.ageBuckets)
+		}
+	}
+
+	for name, u := range src.users {
+		dstUser, ok := dst.users[name]
+		if !ok {
+			dstUser = &userInfo{name: name, filetypes: make(map[string]*userFileType), ageBuckets: dst.newAgeHistogram()}
+			dst.users[name] = dstUser
+		}
+		dstUser.size += u.size
+		dstUser.count += u.count
+		mergeAgeBuckets(dstUser.ageBuckets, u.ageBuckets)
+		for extension, uft := range u.filetypes {
+			dstUft, ok := dstUser.filetypes[extension]
+			if !ok {
+				dstUft = &userFileType{extension: extension, ageBuckets: dst.newAgeHistogram()}
+				dstUser.filetypes[extension] = dstUft
+			}
+			dstUft.size += uft.size
+			dstUft.count += uft.count
+			mergeAgeBuckets(dstUft.ageBuckets, uft.ageBuckets)
+		}
+	}
+
+	for p, rec := range src.files {
+		dst.files[p] = rec
+	}
+
+	for p, s := range src.dirs {
+		dstDir, ok := dst.dirs[p]
+		if !ok {
+			dstDir = &dirStats{}
+			dst.dirs[p] = dstDir
+		}
+		dstDir.ownSize += s.ownSize
+		dstDir.ownCount += s.ownCount
+	}
+
+	for _, c := range src.topFiles {
+		offerFile(&dst.topFiles, dst.topN, c)
+	}
 }
 
 // defining type used to sort the file extension information by size descending
@@ -86,9 +243,9 @@ func humanReadableSize(size int64) string {
 }
 
 // func spinner is used to display a spinner on the command line while the program is running
-func spinner(stop chan bool, totalFilesCount *int64, totalDirectoriesCount *int64, start *time.Time) {
+func spinner(stop chan bool, totalFilesCount *atomic.Int64, totalDirectoriesCount *atomic.Int64, start *time.Time) {
 	// Define the frames for the spinner
-	frames := []string{"◐", "◓", "◑", "◒", "\u26A1"}
+	frames := []string{"◐", "◓", "◑", "◒", "⚡"}
 	for {
 		select {
 		case <-stop:
@@ -97,8 +254,8 @@ func spinner(stop chan bool, totalFilesCount *int64, totalDirectoriesCount *int6
 		default:
 			for _, frame := range frames {
 				duration := time.Since(*start)
-				rate := float64(*totalFilesCount) / duration.Seconds()
-				fmt.Printf("\r%s Scanning... Files scanned: %d Directories scanned: %d Rate: %.0f files/second \033[0K", frame, *totalFilesCount, *totalDirectoriesCount, rate)
+				rate := float64(totalFilesCount.Load()) / duration.Seconds()
+				fmt.Printf("\r%s Scanning... Files scanned: %d Directories scanned: %d Rate: %.0f files/second \033[0K", frame, totalFilesCount.Load(), totalDirectoriesCount.Load(), rate)
 				time.Sleep(250 * time.Millisecond)
 			}
 		}
@@ -112,6 +269,206 @@ func averageFileSize(fileSize int64, fileCount int64) string {
 	return humanReadableSize(int64(average))
 }
 
+// func sniffContentType reads the first 512 bytes of path and runs them through
+// net/http.DetectContentType, returning a MIME type like "image/png" or
+// "text/plain; charset=utf-8" instead of a coarse binary/text guess
+func sniffContentType(path string) (string, error) {
+	data, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer data.Close()
+
+	buf := make([]byte, 512)
+	n, err := data.Read(buf)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// func classifyFile determines the bucket a file should be counted under: its extension, unless
+// it has none (or classifyAll is set) in which case its content is sniffed via sniffContentType
+func classifyFile(path string, extension string, classifyAll bool, verboseEnabled bool, logger *log.Logger) string {
+	if extension != "" && !classifyAll {
+		return extension
+	}
+	contentType, err := sniffContentType(path)
+	if err != nil {
+		if verboseEnabled {
+			logger.Printf("Error sniffing content type of %s: %s, skipping\n", path, err)
+		}
+		if extension != "" {
+			return extension
+		}
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// func lookupOwner resolves the username that owns a uid, consulting the supplied cache first so
+// that a worker only calls into os/user once per distinct uid it encounters rather than once per file
+func lookupOwner(uid uint32, cache map[uint32]string) string {
+	if name, ok := cache[uid]; ok {
+		return name
+	}
+	var name string
+	owner, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		name = strconv.FormatUint(uint64(uid), 10)
+	} else {
+		name = owner.Username
+	}
+	cache[uid] = name
+	return name
+}
+
+// dirQueue is an unbounded FIFO of directories a worker has discovered but not yet handed off to
+// jobs, drained by a single dispatch goroutine. Without it, a directory with many subdirectories
+// would need one blocked goroutine per pending send whenever the bounded jobs channel is full;
+// queuing here instead keeps the goroutine count fixed regardless of how bursty the tree is.
+type dirQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []string
+	closed bool
+}
+
+// func newDirQueue allocates an empty dirQueue ready for push/dispatch
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// func push appends dir to q, waking dispatch if it is waiting on an empty queue
+func (q *dirQueue) push(dir string) {
+	q.mu.Lock()
+	q.buf = append(q.buf, dir)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// func close marks q closed, so dispatch returns once it has drained whatever is left in buf
+func (q *dirQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// func dispatch forwards directories pushed onto q to jobs, one at a time, blocking on a full jobs
+// channel instead of spinning up a goroutine per pending send. It returns once q has been closed
+// and fully drained; scan runs exactly one of these per call.
+func (q *dirQueue) dispatch(jobs chan<- string) {
+	for {
+		q.mu.Lock()
+		for len(q.buf) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.buf) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		dir := q.buf[0]
+		q.buf = q.buf[1:]
+		q.mu.Unlock()
+		jobs <- dir
+	}
+}
+
+// func worker pulls directory paths off jobs until the channel is closed and drained. For each
+// directory it reads the entries with os.ReadDir, pushes newly discovered subdirectories onto
+// dirQ, and records every file it finds into its own local scanResult - so that the only shared,
+// mutable state a worker touches while walking is the job channel, dirQ, the pending-directory
+// WaitGroup wg and the atomic counters.
+func worker(jobs chan string, dirQ *dirQueue, wg *sync.WaitGroup, result *scanResult, totalFilesCount *atomic.Int64, totalDirectoriesCount *atomic.Int64, classifyAll bool, verboseEnabled bool, logger *log.Logger) {
+	uidCache := make(map[uint32]string)
+
+	for dir := range jobs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if verboseEnabled {
+				logger.Printf("Error reading directory %s: %s, skipping\n", dir, err)
+			}
+			wg.Done()
+			continue
+		}
+		totalDirectoriesCount.Add(1)
+
+		for _, entry := range entries {
+			entryPath := filepath.Join(dir, entry.Name())
+
+			if entry.IsDir() {
+				wg.Add(1)
+				dirQ.push(entryPath)
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				if verboseEnabled {
+					logger.Printf("Error stating %s: %s, skipping\n", entryPath, err)
+				}
+				continue
+			}
+
+			totalFilesCount.Add(1)
+
+			extension := classifyFile(entryPath, filepath.Ext(entryPath), classifyAll, verboseEnabled, logger)
+
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				continue
+			}
+			owner := lookupOwner(stat.Uid, uidCache)
+
+			result.addFile(entryPath, extension, owner, stat.Uid, info.Size(), info.ModTime().Unix())
+		}
+		wg.Done()
+	}
+}
+
+// func scan walks directory with a bounded pool of workerCount workers and returns the merged
+// file-type and user aggregates once every directory under it has been processed
+func scan(directory string, workerCount int, totalFilesCount *atomic.Int64, totalDirectoriesCount *atomic.Int64, classifyAll bool, topN int, ageBoundaries []int64, now int64, verboseEnabled bool, logger *log.Logger) *scanResult {
+	jobs := make(chan string, workerCount*4)
+	dirQ := newDirQueue()
+	go dirQ.dispatch(jobs)
+
+	var dirsPending sync.WaitGroup
+	var pool sync.WaitGroup
+
+	results := make([]*scanResult, workerCount)
+	for i := 0; i < workerCount; i++ {
+		results[i] = newScanResult(topN, ageBoundaries, now)
+		pool.Add(1)
+		go func(i int) {
+			defer pool.Done()
+			worker(jobs, dirQ, &dirsPending, results[i], totalFilesCount, totalDirectoriesCount, classifyAll, verboseEnabled, logger)
+		}(i)
+	}
+
+	dirsPending.Add(1)
+	dirQ.push(directory)
+
+	// once every directory that has been queued has also been processed, no worker can
+	// possibly queue another one, so it is safe to close dirQ and the jobs channel
+	go func() {
+		dirsPending.Wait()
+		dirQ.close()
+		close(jobs)
+	}()
+
+	pool.Wait()
+
+	merged := newScanResult(topN, ageBoundaries, now)
+	for _, r := range results {
+		mergeScanResult(merged, r)
+	}
+	return merged
+}
+
 func main() {
 
 	// define command line arguments
@@ -122,6 +479,7 @@ func main() {
 	// -f print out only the file types/extensions information
 	// -u print out only the user information
 	// -t log file target directory
+	// -p number of concurrent workers used to walk the directory tree
 
 	// Create a channel to receive signals
 	sigCh := make(chan os.Signal, 1)
@@ -134,6 +492,15 @@ func main() {
 	fileTypesOnly := false
 	userInfoOnly := false
 	loggingTargetDirectory := "/tmp"
+	workerCount := runtime.NumCPU()
+	savePath := ""
+	loadPath := ""
+	diffPath := ""
+	fullRescan := false
+	outputFormat := "text"
+	classifyAll := false
+	topN := 20
+	ageBucketsFlag := defaultAgeBuckets
 
 	flag.BoolVar(&loggingEnabled, "l", false, "enable logging")
 	flag.StringVar(&loggingTargetDirectory, "t", "/tmp", "log file target directory")
@@ -142,9 +509,36 @@ func main() {
 	flag.BoolVar(&buildInfo, "i", false, "print out the build information")
 	flag.BoolVar(&fileTypesOnly, "f", false, "print out only the file types/extensions information")
 	flag.BoolVar(&userInfoOnly, "u", false, "print out only the user information")
+	flag.IntVar(&workerCount, "p", runtime.NumCPU(), "number of concurrent workers used to walk the directory tree")
+	flag.StringVar(&savePath, "save", "", "save the resulting index to this path")
+	flag.StringVar(&loadPath, "load", "", "load a previously saved index from this path instead of rescanning")
+	flag.StringVar(&diffPath, "diff", "", "apply a zfs/btrfs style diff file to a loaded index instead of rescanning")
+	flag.BoolVar(&fullRescan, "full", false, "ignore -diff and force a full rescan")
+	flag.StringVar(&outputFormat, "o", "text", "output format: text, json, ndjson or csv")
+	flag.BoolVar(&classifyAll, "classify-all", false, "sniff the content type of every file, not just extensionless ones (extra I/O)")
+	flag.IntVar(&topN, "topN", 20, "number of largest files and largest directories to report")
+	flag.StringVar(&ageBucketsFlag, "age-buckets", defaultAgeBuckets, "comma-separated, strictly increasing day boundaries for the per-user/per-extension age histogram")
 
 	flag.Parse()
 
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
+	reporter, err := reporterFor(outputFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ageBoundaries, err := parseAgeBuckets(ageBucketsFlag)
+	if err != nil {
+		log.Fatalf("Invalid -age-buckets: %v", err)
+	}
+
+	if diffPath != "" && loadPath == "" {
+		log.Fatalf("-diff requires -load: there is no index to apply %s against", diffPath)
+	}
+
 	if buildInfo {
 		fmt.Printf("Build date:\t%s\n"+
 			"From branch:\t%s\n"+
@@ -186,17 +580,9 @@ func main() {
 	stop := make(chan bool)
 
 	var directory = directoryToScan
-	//logger.Printf("Target directory: %s\n", directory)
 
-	//defining lists used to hold the filetype information
-	var fileTypes []fileType
-
-	//defining lists used to hold the user information
-	var users []userInfo
-
-	var totalFilesCount int64
-	var totalCapacity int64
-	var totalDirectoriesCount int64
+	var totalFilesCount atomic.Int64
+	var totalDirectoriesCount atomic.Int64
 
 	// starting a timer later used to calculate the time it took to scan the directory and to calculate the scan rate
 	start := time.Now()
@@ -207,273 +593,88 @@ func main() {
 		log.Fatal("Error getting current user: ", err)
 	}
 
-	logger.Printf("Scanning directory: %s\n", directory)
-	logger.Printf("Scanning as user: %s\n", currentUser.Username)
-
-	// starting the spinner
-	go spinner(stop, &totalFilesCount, &totalDirectoriesCount, &start)
+	var idx *index
+	var result *scanResult
 
-	// starting the walk of the directory down into the rabbit hole
-	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+	// a loaded index lets us apply a diff (or just re-report) without walking the filesystem at all
+	if loadPath != "" && !fullRescan {
+		loaded, err := loadIndex(loadPath)
 		if err != nil {
-			if verboseEnabled {
-				logger.Printf("Error walking directory %s: %s, skipping\n", path, err)
-			} else {
-				return nil
-			}
-		} else {
-			if verboseEnabled {
-				logger.Printf("Walking directory %s\n", path)
-			}
+			log.Fatalf("Failed to load index from %s: %v", loadPath, err)
 		}
-		// if the is not a directory, process the file
-		if !info.IsDir() {
-			//
-			totalFilesCount++
-
-			// getting the extension of the file
-			extension := filepath.Ext(path)
-
-			// if the file has no extension we will try to determine if its binary or plain text/ASCII
-			if extension == "" {
-				data, err := os.Open(path)
-				if err != nil {
-					// as we cannot determine if its binary or text we will just call it unknown
-					extension = "no_extension_unknown_format"
-					if verboseEnabled {
-						logger.Printf("Error opening %s: %s, skipping\n", path, err)
-					} else {
-						return nil
-					}
-					// closing the file
-					defer func(data *os.File) {
-						err := data.Close()
-						if err != nil {
-							if verboseEnabled {
-								logger.Printf("Error closing %s: %s, skipping\n", path, err)
-							} else {
-								return
-							}
-						}
-					}(data)
-					if verboseEnabled {
-						logger.Printf("Error opening %s: %s, skipping\n", path, err)
-					} else {
-						return nil
-					}
-				} else {
-					// if we can open the file we will try to determine if its binary or text
-					isBinary := false
-
-					// reading the first 10 lines of the file
-					fileScanner := bufio.NewScanner(data)
-					for i := 0; i < 10 && fileScanner.Scan(); i++ {
-
-						// if we find a character that is not in the range of 32-126 we will assume its binary
-						line := fileScanner.Text()
-						for _, c := range line {
-							if c < 32 || c > 126 {
-								isBinary = true
-								break
-							}
-						}
-						if isBinary {
-							extension = "no_extension_binary"
-						} else {
-							extension = "no_extension_text"
-						}
-					}
-					// closing the file
-					defer func(data *os.File) {
-						err := data.Close()
-						if err != nil {
-							if verboseEnabled {
-								logger.Printf("Error closing logFile %s: %s, skipping\n", path, err)
-							} else {
-								return
-							}
-						} else {
-							if verboseEnabled {
-								logger.Printf("Successfully closed logFile %s\n", path)
-							}
-						}
-					}(data)
-				}
+		idx = loaded
+		directory = idx.Root
+		result = aggregateFromIndex(idx, topN, ageBoundaries, start.Unix())
+		totalFilesCount.Store(int64(len(idx.Records)))
+		logger.Printf("Loaded index for %s (%d files, generated %s)\n", idx.Root, len(idx.Records), idx.GeneratedAt.Format(time.RFC3339))
+
+		if diffPath != "" {
+			if err := applyDiff(diffPath, idx, result, classifyAll, verboseEnabled, logger); err != nil {
+				log.Fatalf("Failed to apply diff %s: %v", diffPath, err)
 			}
-			//getting the size of the file in bytes
-			size := info.Size()
+			totalFilesCount.Store(int64(len(idx.Records)))
+			logger.Printf("Applied diff %s\n", diffPath)
+		}
+	} else {
+		logger.Printf("Scanning directory: %s\n", directory)
+		logger.Printf("Scanning as user: %s\n", currentUser.Username)
+		logger.Printf("Using %d workers\n", workerCount)
 
-			// adding the size to the total size
-			totalCapacity += size
+		// starting the spinner
+		go spinner(stop, &totalFilesCount, &totalDirectoriesCount, &start)
 
-			// getting the owner of the file
-			owner, err := user.LookupId(fmt.Sprintf("%d", info.Sys().(*syscall.Stat_t).Uid))
-			// if we cannot get the owner we will just use the uid
-			if err != nil {
-				owner = &user.User{Uid: fmt.Sprintf("%d", info.Sys().(*syscall.Stat_t).Uid)}
-				if verboseEnabled {
-					logger.Printf("Error getting owner of %s: %s, using uid instead\n", path, err)
-				} else {
-					return nil
-				}
-			} else {
-				if verboseEnabled {
-					logger.Printf("Successfully got owner of %s: %s\n", path, owner.Username)
-				}
-			}
-			// checking if the extension is already in the list
-			extensionFound := false
-
-			// looping through the list of file extensions
-			for i := range fileTypes {
-
-				// if the extension is already in the list we will add the size to the total size and increase the count
-				if fileTypes[i].extension == extension {
-					fileTypes[i].size += size
-					fileTypes[i].count++
-					extensionFound = true
-
-					// checking if the user is already in the list
-					userFound := false
-
-					// looping through the list of users
-					for j := range fileTypes[i].users {
-
-						// if the user is already in the list we will add the size to the total size and increase the count
-						if fileTypes[i].users[j].name == owner.Username {
-							fileTypes[i].users[j].size += size
-							fileTypes[i].users[j].count++
-							userFound = true
-							// breaking out of the loop
-							break
-						}
-					}
-					// if the user is not in the list we will add it
-					if !userFound {
-						fileTypes[i].users = append(fileTypes[i].users, fileTypeUserInfo{
-							name:  owner.Username,
-							size:  size,
-							count: 1,
-						})
-					}
-					// breaking out of the loop
-					break
-				}
-			}
-
-			// if the extension is not in the list we will add it
-			if !extensionFound {
-				fileTypes = append(fileTypes, fileType{
-					extension: extension,
-					size:      size,
-					count:     1,
-					users: []fileTypeUserInfo{{
-						name:  owner.Username,
-						size:  size,
-						count: 1,
-					}},
-				})
-			}
+		// walking the directory tree with a bounded pool of workers, aggregating as we go
+		result = scan(directory, workerCount, &totalFilesCount, &totalDirectoriesCount, classifyAll, topN, ageBoundaries, start.Unix(), verboseEnabled, logger)
+		stop <- true
 
-			// checking if the user is already in the list
-			extensionUserFound := false
-
-			// looping through the list of users
-			for i := range users {
-
-				//if the user is already in the list we will add the size to the total size and increase the count
-				if users[i].name == owner.Username {
-					users[i].size += size
-					users[i].count++
-					extensionUserFound = true
-
-					// checking if the extension is already in the list
-					userFileExtensionFound := false
-
-					// looping through the list of extensions
-					for j := range users[i].filetypes {
-						// if the extension is already in the list we will add the size to the total size and increase the count
-						if users[i].filetypes[j].extension == extension {
-							users[i].filetypes[j].size += size
-							users[i].filetypes[j].count++
-							userFileExtensionFound = true
-
-							// breaking out of the loop
-							break
-						}
-					}
-					//checking if the extension is not in the list
-					if !userFileExtensionFound {
-						// adding the extension to the list
-						users[i].filetypes = append(users[i].filetypes, userFileType{
-							extension: extension,
-							size:      size,
-							count:     1,
-						})
-					}
-
-					// breaking out of the loop
-					break
-				}
-			}
-			// if the user is not in the list we will add it
-			if !extensionUserFound {
-
-				// adding the user to the list
-				users = append(users, userInfo{
-					name:  owner.Username,
-					size:  size,
-					count: 1,
-					filetypes: []userFileType{{
-						extension: extension,
-						size:      size,
-						count:     1,
-					}},
-				})
-			}
+		idx = newIndexFromResult(directory, result)
+	}
 
+	if savePath != "" {
+		if err := saveIndex(savePath, idx); err != nil {
+			logger.Printf("Error saving index to %s: %s\n", savePath, err)
 		} else {
-
-			// if the entry is a directory we will increase the total number of directories
-			totalDirectoriesCount++
+			logger.Printf("Saved index to %s\n", savePath)
 		}
-		return nil
-	})
-
-	sort.Sort(bySize(fileTypes))
+	}
 
-	sort.Sort(bySizeUser(users))
+	var totalCapacity int64
+	fileTypes := make([]fileType, 0, len(result.fileTypes))
+	for _, ft := range result.fileTypes {
+		totalCapacity += ft.size
+		fileTypes = append(fileTypes, *ft)
+	}
 
-	stop <- true
+	users := make([]userInfo, 0, len(result.users))
+	for _, u := range result.users {
+		users = append(users, *u)
+	}
 
-	fmt.Println("")
-	logger.Printf("Total capacity: %s Total files: %d, Total directories: %d\n", humanReadableSize(totalCapacity), totalFilesCount, totalDirectoriesCount)
-	logger.Printf("Total scanning time: %s\n", time.Since(start).Truncate(time.Millisecond).String())
+	sort.Sort(bySize(fileTypes))
 
-	if !fileTypesOnly {
+	sort.Sort(bySizeUser(users))
 
-		logger.Printf("Consumption by user:\n")
-		for _, userEntry := range users {
-			fmt.Printf("\t%s: Capacity: %s, #of files: %d, average file size: %s \n", userEntry.name, humanReadableSize(userEntry.size), userEntry.count, averageFileSize(userEntry.size, userEntry.count))
-			if verboseEnabled {
-				for _, ft := range userEntry.filetypes {
-					fmt.Printf("\t\t%s: %s #of files: %d average file size: %s\n", ft.extension, humanReadableSize(ft.size), ft.count, averageFileSize(ft.size, ft.count))
-				}
-			}
-		}
+	rpt := &report{
+		ScanRoot:         directory,
+		StartedAt:        start,
+		DurationMs:       time.Since(start).Milliseconds(),
+		TotalFiles:       totalFilesCount.Load(),
+		TotalDirectories: totalDirectoriesCount.Load(),
+		TotalBytes:       totalCapacity,
+		FileTypes:        fileTypes,
+		Users:            users,
+		TopN:             topN,
+		TopFiles:         drainFilesDescending(&result.topFiles),
+		TopDirectories:   topDirectoriesFromStats(result.dirs, topN, directory),
+		AgeBucketLabels:  ageBucketLabels(ageBoundaries),
 	}
-	if userInfoOnly {
-		os.Exit(0)
+	opts := reportOptions{
+		Verbose:       verboseEnabled,
+		FileTypesOnly: fileTypesOnly,
+		UserInfoOnly:  userInfoOnly,
+		Logger:        logger,
 	}
-
-	fmt.Println("")
-	logger.Printf("Consumption by file type/extension:\n")
-	for _, fileTypeEntry := range fileTypes {
-		fmt.Printf("\t%s: %s, #of files %d, average filesize: %s\n", fileTypeEntry.extension, humanReadableSize(fileTypeEntry.size), fileTypeEntry.count, averageFileSize(fileTypeEntry.size, fileTypeEntry.count))
-		if verboseEnabled {
-			for _, userEntry := range fileTypeEntry.users {
-				fmt.Printf("\t\t%s: Capacity %s, #of files %d, average filesize: %s \n", userEntry.name, humanReadableSize(userEntry.size), userEntry.count, averageFileSize(userEntry.size, userEntry.count))
-			}
-		}
+	if err := reporter.Report(os.Stdout, rpt, opts); err != nil {
+		log.Fatalf("Failed to render report: %v", err)
 	}
 }
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+// TestMergeScanResultSumsAcrossWorkers exercises the worker-pool merge path: each worker
+// accumulates its own scanResult, and mergeScanResult folds them into one. This matches what
+// scan does with results[i] before returning, just without the filesystem walk.
+func TestMergeScanResultSumsAcrossWorkers(t *testing.T) {
+	boundaries := []int64{7, 30}
+	now := int64(1_000_000)
+
+	a := newScanResult(2, boundaries, now)
+	a.addFile("/data/a/1.txt", ".txt", "alice", 1, 100, now-1*86400)
+	a.addFile("/data/a/2.txt", ".txt", "alice", 1, 200, now-10*86400)
+
+	b := newScanResult(2, boundaries, now)
+	b.addFile("/data/b/3.txt", ".txt", "bob", 2, 50, now-40*86400)
+	b.addFile("/data/b/4.bin", ".bin", "bob", 2, 400, now-1*86400)
+
+	merged := newScanResult(2, boundaries, now)
+	mergeScanResult(merged, a)
+	mergeScanResult(merged, b)
+
+	txt, ok := merged.fileTypes[".txt"]
+	if !ok {
+		t.Fatalf("expected .txt fileType to exist after merge")
+	}
+	if txt.size != 350 || txt.count != 3 {
+		t.Errorf(".txt size/count = %d/%d, want 350/3", txt.size, txt.count)
+	}
+
+	bin, ok := merged.fileTypes[".bin"]
+	if !ok || bin.size != 400 || bin.count != 1 {
+		t.Errorf(".bin fileType = %+v, want size 400 count 1", bin)
+	}
+
+	alice, ok := merged.users["alice"]
+	if !ok || alice.size != 300 || alice.count != 2 {
+		t.Errorf("alice = %+v, want size 300 count 2", alice)
+	}
+	bob, ok := merged.users["bob"]
+	if !ok || bob.size != 450 || bob.count != 2 {
+		t.Errorf("bob = %+v, want size 450 count 2", bob)
+	}
+
+	if len(merged.files) != 4 {
+		t.Errorf("len(merged.files) = %d, want 4", len(merged.files))
+	}
+
+	// <7d bucket should only hold the two 1-day-old files (100 + 400), merged across workers
+	if got := txt.ageBuckets[0].size + bin.ageBuckets[0].size; got != 500 {
+		t.Errorf("<7d bucket total = %d, want 500", got)
+	}
+}
+
+// TestAddFileTracksDirectoryOwnTotals verifies addFile attributes a file's size/count to its
+// immediate parent directory only, the per-directory bookkeeping topDirectoriesFromStats builds
+// cumulative subtree totals from.
+func TestAddFileTracksDirectoryOwnTotals(t *testing.T) {
+	r := newScanResult(5, nil, 0)
+	r.addFile("/data/a/1.txt", ".txt", "alice", 1, 100, 0)
+	r.addFile("/data/a/2.txt", ".txt", "alice", 1, 200, 0)
+	r.addFile("/data/a/b/3.txt", ".txt", "alice", 1, 50, 0)
+
+	dirA, ok := r.dirs["/data/a"]
+	if !ok || dirA.ownSize != 300 || dirA.ownCount != 2 {
+		t.Errorf("/data/a dirStats = %+v, want ownSize 300 ownCount 2", dirA)
+	}
+	dirAB, ok := r.dirs["/data/a/b"]
+	if !ok || dirAB.ownSize != 50 || dirAB.ownCount != 1 {
+		t.Errorf("/data/a/b dirStats = %+v, want ownSize 50 ownCount 1", dirAB)
+	}
+}
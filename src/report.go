@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+// report is everything the final report needs, independent of which Reporter ends up rendering it
+type report struct {
+	ScanRoot         string
+	StartedAt        time.Time
+	DurationMs       int64
+	TotalFiles       int64
+	TotalDirectories int64
+	TotalBytes       int64
+	FileTypes        []fileType
+	Users            []userInfo
+	TopN             int
+	TopFiles         []fileCandidate
+	TopDirectories   []dirCandidate
+	AgeBucketLabels  []string
+}
+
+// reportOptions carries the CLI switches that affect how a Reporter renders a report
+type reportOptions struct {
+	Verbose       bool
+	FileTypesOnly bool
+	UserInfoOnly  bool
+	Logger        *log.Logger
+}
+
+// Reporter renders a report to w in its own output format
+type Reporter interface {
+	Report(w io.Writer, rpt *report, opts reportOptions) error
+}
+
+// func reporterFor resolves the -o flag value to a Reporter, or an error for an unknown format
+func reporterFor(format string) (Reporter, error) {
+	switch format {
+	case "", "text":
+		return TextReporter{}, nil
+	case "json":
+		return JSONReporter{}, nil
+	case "ndjson":
+		return NDJSONReporter{}, nil
+	case "csv":
+		return CSVReporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want text, json, ndjson or csv)", format)
+	}
+}
+
+// TextReporter renders dirscan's human-readable console report: the original per-user and
+// per-extension rollups, each followed by an age histogram row, then the top-N files and
+// directories sections
+type TextReporter struct{}
+
+func (TextReporter) Report(w io.Writer, rpt *report, opts reportOptions) error {
+	fmt.Fprintln(w)
+	if opts.Logger != nil {
+		opts.Logger.Printf("Total capacity: %s Total files: %d, Total directories: %d\n", humanReadableSize(rpt.TotalBytes), rpt.TotalFiles, rpt.TotalDirectories)
+		opts.Logger.Printf("Total scanning time: %s\n", time.Duration(rpt.DurationMs)*time.Millisecond)
+	}
+
+	if !opts.FileTypesOnly {
+		if opts.Logger != nil {
+			opts.Logger.Printf("Consumption by user:\n")
+		}
+		for _, userEntry := range rpt.Users {
+			fmt.Fprintf(w, "\t%s: Capacity: %s, #of files: %d, average file size: %s \n", userEntry.name, humanReadableSize(userEntry.size), userEntry.count, averageFileSize(userEntry.size, userEntry.count))
+			if len(rpt.AgeBucketLabels) > 0 {
+				fmt.Fprintf(w, "\t\tage: %s\n", formatAgeHistogram(rpt.AgeBucketLabels, userEntry.ageBuckets))
+			}
+			if opts.Verbose {
+				for _, ft := range userEntry.filetypes {
+					fmt.Fprintf(w, "\t\t%s: %s #of files: %d average file size: %s\n", ft.extension, humanReadableSize(ft.size), ft.count, averageFileSize(ft.size, ft.count))
+				}
+			}
+		}
+	}
+	if opts.UserInfoOnly {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	if opts.Logger != nil {
+		opts.Logger.Printf("Consumption by file type/extension:\n")
+	}
+	for _, fileTypeEntry := range rpt.FileTypes {
+		fmt.Fprintf(w, "\t%s: %s, #of files %d, average filesize: %s\n", fileTypeEntry.extension, humanReadableSize(fileTypeEntry.size), fileTypeEntry.count, averageFileSize(fileTypeEntry.size, fileTypeEntry.count))
+		if len(rpt.AgeBucketLabels) > 0 {
+			fmt.Fprintf(w, "\t\tage: %s\n", formatAgeHistogram(rpt.AgeBucketLabels, fileTypeEntry.ageBuckets))
+		}
+		if opts.Verbose {
+			for _, userEntry := range fileTypeEntry.users {
+				fmt.Fprintf(w, "\t\t%s: Capacity %s, #of files %d, average filesize: %s \n", userEntry.name, humanReadableSize(userEntry.size), userEntry.count, averageFileSize(userEntry.size, userEntry.count))
+			}
+		}
+	}
+
+	if len(rpt.TopFiles) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Top %d files:\n", len(rpt.TopFiles))
+		for _, f := range rpt.TopFiles {
+			fmt.Fprintf(w, "\t%s: %s, owner: %s, modified: %s\n", f.path, humanReadableSize(f.size), f.owner, time.Unix(f.mtime, 0).Format(time.RFC3339))
+		}
+	}
+
+	if len(rpt.TopDirectories) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Top %d directories:\n", len(rpt.TopDirectories))
+		for _, d := range rpt.TopDirectories {
+			fmt.Fprintf(w, "\t%s: %s, #of files %d\n", d.path, humanReadableSize(d.size), d.count)
+		}
+	}
+
+	return nil
+}
+
+// jsonDocument is the single-document shape emitted by JSONReporter
+type jsonDocument struct {
+	ScanRoot       string              `json:"scan_root"`
+	StartedAt      time.Time           `json:"started_at"`
+	DurationMs     int64               `json:"duration_ms"`
+	TotalFiles     int64               `json:"total_files"`
+	TotalBytes     int64               `json:"total_bytes"`
+	ByExtension    []jsonExtensionItem `json:"by_extension"`
+	ByUser         []jsonUserItem      `json:"by_user"`
+	TopFiles       []jsonFileItem      `json:"top_files"`
+	TopDirectories []jsonDirItem       `json:"top_directories"`
+}
+
+type jsonExtensionItem struct {
+	Extension  string              `json:"extension"`
+	Size       int64               `json:"size"`
+	Count      int64               `json:"count"`
+	AgeBuckets []jsonAgeBucketItem `json:"age_buckets,omitempty"`
+}
+
+type jsonUserItem struct {
+	User       string              `json:"user"`
+	Size       int64               `json:"size"`
+	Count      int64               `json:"count"`
+	AgeBuckets []jsonAgeBucketItem `json:"age_buckets,omitempty"`
+}
+
+type jsonAgeBucketItem struct {
+	Label string `json:"label"`
+	Size  int64  `json:"size"`
+	Count int64  `json:"count"`
+}
+
+// func jsonAgeBuckets zips labels with buckets into the per-item age_buckets array shared by
+// jsonExtensionItem and jsonUserItem
+func jsonAgeBuckets(labels []string, buckets []ageBucketStat) []jsonAgeBucketItem {
+	items := make([]jsonAgeBucketItem, len(buckets))
+	for i, b := range buckets {
+		items[i] = jsonAgeBucketItem{Label: labels[i], Size: b.size, Count: b.count}
+	}
+	return items
+}
+
+type jsonFileItem struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Owner    string    `json:"owner"`
+	Modified time.Time `json:"modified"`
+}
+
+type jsonDirItem struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Count int64  `json:"count"`
+}
+
+// JSONReporter emits a single JSON document summarizing the scan, for dashboards and other
+// one-shot consumers
+type JSONReporter struct{}
+
+func (JSONReporter) Report(w io.Writer, rpt *report, _ reportOptions) error {
+	doc := jsonDocument{
+		ScanRoot:       rpt.ScanRoot,
+		StartedAt:      rpt.StartedAt,
+		DurationMs:     rpt.DurationMs,
+		TotalFiles:     rpt.TotalFiles,
+		TotalBytes:     rpt.TotalBytes,
+		ByExtension:    make([]jsonExtensionItem, 0, len(rpt.FileTypes)),
+		ByUser:         make([]jsonUserItem, 0, len(rpt.Users)),
+		TopFiles:       make([]jsonFileItem, 0, len(rpt.TopFiles)),
+		TopDirectories: make([]jsonDirItem, 0, len(rpt.TopDirectories)),
+	}
+	for _, ft := range rpt.FileTypes {
+		doc.ByExtension = append(doc.ByExtension, jsonExtensionItem{Extension: ft.extension, Size: ft.size, Count: ft.count, AgeBuckets: jsonAgeBuckets(rpt.AgeBucketLabels, ft.ageBuckets)})
+	}
+	for _, u := range rpt.Users {
+		doc.ByUser = append(doc.ByUser, jsonUserItem{User: u.name, Size: u.size, Count: u.count, AgeBuckets: jsonAgeBuckets(rpt.AgeBucketLabels, u.ageBuckets)})
+	}
+	for _, f := range rpt.TopFiles {
+		doc.TopFiles = append(doc.TopFiles, jsonFileItem{Path: f.path, Size: f.size, Owner: f.owner, Modified: time.Unix(f.mtime, 0)})
+	}
+	for _, d := range rpt.TopDirectories {
+		doc.TopDirectories = append(doc.TopDirectories, jsonDirItem{Path: d.path, Size: d.size, Count: d.count})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// ndjsonRecord is one line of NDJSONReporter's output: an extension, user, extension age bucket,
+// user age bucket, top-file or top-directory rollup
+type ndjsonRecord struct {
+	Type      string `json:"type"`
+	Extension string `json:"extension,omitempty"`
+	User      string `json:"user,omitempty"`
+	Path      string `json:"path,omitempty"`
+	AgeBucket string `json:"age_bucket,omitempty"`
+	Size      int64  `json:"size"`
+	Count     int64  `json:"count,omitempty"`
+}
+
+// NDJSONReporter emits one JSON object per line, one per file-type, user, age bucket of each
+// file-type and user, top file and top directory, so a log pipeline can consume the report as a stream instead of parsing a single document
+type NDJSONReporter struct{}
+
+func (NDJSONReporter) Report(w io.Writer, rpt *report, _ reportOptions) error {
+	enc := json.NewEncoder(w)
+	for _, ft := range rpt.FileTypes {
+		if err := enc.Encode(ndjsonRecord{Type: "extension", Extension: ft.extension, Size: ft.size, Count: ft.count}); err != nil {
+			return err
+		}
+		for i, b := range ft.ageBuckets {
+			if err := enc.Encode(ndjsonRecord{Type: "extension_age_bucket", Extension: ft.extension, AgeBucket: rpt.AgeBucketLabels[i], Size: b.size, Count: b.count}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, u := range rpt.Users {
+		if err := enc.Encode(ndjsonRecord{Type: "user", User: u.name, Size: u.size, Count: u.count}); err != nil {
+			return err
+		}
+		for i, b := range u.ageBuckets {
+			if err := enc.Encode(ndjsonRecord{Type: "user_age_bucket", User: u.name, AgeBucket: rpt.AgeBucketLabels[i], Size: b.size, Count: b.count}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range rpt.TopFiles {
+		if err := enc.Encode(ndjsonRecord{Type: "top_file", Path: f.path, User: f.owner, Size: f.size}); err != nil {
+			return err
+		}
+	}
+	for _, d := range rpt.TopDirectories {
+		if err := enc.Encode(ndjsonRecord{Type: "top_directory", Path: d.path, Size: d.size, Count: d.count}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CSVReporter emits six CSV tables - by-extension, by-user, by-extension age histogram, by-user
+// age histogram, top-files and top-directories - each separated by a blank line
+type CSVReporter struct{}
+
+func (CSVReporter) Report(w io.Writer, rpt *report, _ reportOptions) error {
+	writeTable := func(header []string, rows [][]string) error {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	extensionRows := make([][]string, 0, len(rpt.FileTypes))
+	for _, ft := range rpt.FileTypes {
+		extensionRows = append(extensionRows, []string{ft.extension, strconv.FormatInt(ft.size, 10), strconv.FormatInt(ft.count, 10)})
+	}
+	if err := writeTable([]string{"extension", "size_bytes", "count"}, extensionRows); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	userRows := make([][]string, 0, len(rpt.Users))
+	for _, u := range rpt.Users {
+		userRows = append(userRows, []string{u.name, strconv.FormatInt(u.size, 10), strconv.FormatInt(u.count, 10)})
+	}
+	if err := writeTable([]string{"user", "size_bytes", "count"}, userRows); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	extensionAgeRows := make([][]string, 0, len(rpt.FileTypes)*len(rpt.AgeBucketLabels))
+	for _, ft := range rpt.FileTypes {
+		for i, b := range ft.ageBuckets {
+			extensionAgeRows = append(extensionAgeRows, []string{ft.extension, rpt.AgeBucketLabels[i], strconv.FormatInt(b.size, 10), strconv.FormatInt(b.count, 10)})
+		}
+	}
+	if err := writeTable([]string{"extension", "age_bucket", "size_bytes", "count"}, extensionAgeRows); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	userAgeRows := make([][]string, 0, len(rpt.Users)*len(rpt.AgeBucketLabels))
+	for _, u := range rpt.Users {
+		for i, b := range u.ageBuckets {
+			userAgeRows = append(userAgeRows, []string{u.name, rpt.AgeBucketLabels[i], strconv.FormatInt(b.size, 10), strconv.FormatInt(b.count, 10)})
+		}
+	}
+	if err := writeTable([]string{"user", "age_bucket", "size_bytes", "count"}, userAgeRows); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	fileRows := make([][]string, 0, len(rpt.TopFiles))
+	for _, f := range rpt.TopFiles {
+		fileRows = append(fileRows, []string{f.path, strconv.FormatInt(f.size, 10), f.owner, time.Unix(f.mtime, 0).Format(time.RFC3339)})
+	}
+	if err := writeTable([]string{"path", "size_bytes", "owner", "modified"}, fileRows); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	dirRows := make([][]string, 0, len(rpt.TopDirectories))
+	for _, d := range rpt.TopDirectories {
+		dirRows = append(dirRows, []string{d.path, strconv.FormatInt(d.size, 10), strconv.FormatInt(d.count, 10)})
+	}
+	return writeTable([]string{"path", "size_bytes", "count"}, dirRows)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"container/heap"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirStats accumulates the files directly contained in one directory (not its subdirectories);
+// subtree totals are derived from these after a scan finishes, see topDirectoriesFromStats.
+type dirStats struct {
+	ownSize  int64
+	ownCount int64
+}
+
+// fileCandidate is one entry in the top-N-largest-files report
+type fileCandidate struct {
+	path  string
+	size  int64
+	owner string
+	mtime int64
+}
+
+// dirCandidate is one entry in the top-N-largest-directories report, size/count being the
+// cumulative totals of the directory's entire subtree
+type dirCandidate struct {
+	path  string
+	size  int64
+	count int64
+}
+
+// fileHeap is a min-heap of fileCandidate ordered by size, used to retain only the topN largest
+// files seen during a scan without holding on to every file
+type fileHeap []fileCandidate
+
+func (h fileHeap) Len() int            { return len(h) }
+func (h fileHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h fileHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *fileHeap) Push(x interface{}) { *h = append(*h, x.(fileCandidate)) }
+func (h *fileHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// func offerFile admits c into h if h has fewer than topN entries yet, or if c is larger than the
+// current smallest entry, keeping h bounded at topN items
+func offerFile(h *fileHeap, topN int, c fileCandidate) {
+	if topN <= 0 {
+		return
+	}
+	if h.Len() < topN {
+		heap.Push(h, c)
+		return
+	}
+	if (*h)[0].size < c.size {
+		(*h)[0] = c
+		heap.Fix(h, 0)
+	}
+}
+
+// func removeFileCandidate drops path's entry from h if it is currently retained there, used when
+// a diff record reports that a previously indexed file was removed or replaced. If path was
+// already evicted by offerFile (because topN smaller files have since taken its place), h is left
+// untouched - there is no way to recover the next-largest candidate without the files we already
+// discarded, so an incremental rescan's top-N can undercount relative to a fresh full scan.
+func removeFileCandidate(h *fileHeap, path string) {
+	for i, c := range *h {
+		if c.path == path {
+			heap.Remove(h, i)
+			return
+		}
+	}
+}
+
+// func drainFilesDescending pops every entry out of h, largest first
+func drainFilesDescending(h *fileHeap) []fileCandidate {
+	result := make([]fileCandidate, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(fileCandidate)
+	}
+	return result
+}
+
+// dirHeap is a min-heap of dirCandidate ordered by cumulative subtree size, the directory
+// equivalent of fileHeap
+type dirHeap []dirCandidate
+
+func (h dirHeap) Len() int            { return len(h) }
+func (h dirHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h dirHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *dirHeap) Push(x interface{}) { *h = append(*h, x.(dirCandidate)) }
+func (h *dirHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+func offerDir(h *dirHeap, topN int, c dirCandidate) {
+	if topN <= 0 {
+		return
+	}
+	if h.Len() < topN {
+		heap.Push(h, c)
+		return
+	}
+	if (*h)[0].size < c.size {
+		(*h)[0] = c
+		heap.Fix(h, 0)
+	}
+}
+
+func drainDirsDescending(h *dirHeap) []dirCandidate {
+	result := make([]dirCandidate, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(dirCandidate)
+	}
+	return result
+}
+
+// func topDirectoriesFromStats turns the per-directory own-totals collected during a scan into
+// cumulative subtree totals, then returns the topN largest. Directories are folded into their
+// parent in order of decreasing depth, so by the time a directory is folded its own subtree total
+// is already complete - a post-order accumulation without needing the walker to recurse explicitly.
+// scanRoot bounds both the ancestor synthesis and the fold so accumulation stops at the directory
+// actually scanned instead of continuing past it to /, home, etc. - directories nothing was ever
+// read from and whose "total" would just be the whole scan repeated under a different name.
+func topDirectoriesFromStats(dirs map[string]*dirStats, topN int, scanRoot string) []dirCandidate {
+	root := filepath.Clean(scanRoot)
+
+	subtreeSize := make(map[string]int64, len(dirs))
+	subtreeCount := make(map[string]int64, len(dirs))
+	for p, s := range dirs {
+		subtreeSize[p] = s.ownSize
+		subtreeCount[p] = s.ownCount
+	}
+
+	// an ancestor directory holding only subdirectories (no files directly) never gets its own
+	// entry in dirs, but it still needs a zero-valued entry here so the fold-upward loop below has
+	// somewhere to accumulate its descendants' totals into - stopping at root rather than walking
+	// all the way up to the filesystem root
+	for p := range dirs {
+		if p == root {
+			continue
+		}
+		for parent := filepath.Dir(p); ; parent = filepath.Dir(parent) {
+			if _, ok := subtreeSize[parent]; ok {
+				break
+			}
+			subtreeSize[parent] = 0
+			subtreeCount[parent] = 0
+			if parent == root || filepath.Dir(parent) == parent {
+				break
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(subtreeSize))
+	for p := range subtreeSize {
+		paths = append(paths, p)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		di := strings.Count(paths[i], string(filepath.Separator))
+		dj := strings.Count(paths[j], string(filepath.Separator))
+		if di != dj {
+			return di > dj
+		}
+		return len(paths[i]) > len(paths[j])
+	})
+
+	for _, p := range paths {
+		if p == root {
+			// root's subtree total is already complete - everything under it has folded
+			// up into it by this point - so it must not also fold into its own parent
+			continue
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			continue
+		}
+		subtreeSize[parent] += subtreeSize[p]
+		subtreeCount[parent] += subtreeCount[p]
+	}
+
+	h := &dirHeap{}
+	for p, size := range subtreeSize {
+		offerDir(h, topN, dirCandidate{path: p, size: size, count: subtreeCount[p]})
+	}
+	return drainDirsDescending(h)
+}